@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheDir holds the on-disk metadata cache, relative to the current
+// working directory (matching bckt-photo.yaml's own default location).
+const cacheDir = ".bckt-photo-cache"
+
+const cacheFile = "cache.json"
+
+// cachePrefixBytes is how much of a file's contents the cache keys hash, so
+// a changed file invalidates its entry without requiring a full re-read of
+// (potentially huge) RAW or video files.
+const cachePrefixBytes = 64 * 1024
+
+// photoCache is the process-wide metadata cache, set up in run() unless
+// --no-cache is passed. readExifData consults it directly, the same way
+// other package state (postsDir, language) is threaded through as a global.
+var photoCache *metadataCache
+
+// cacheEntry is one cached readExifData result, plus the file fingerprint
+// it was computed from.
+type cacheEntry struct {
+	Size       int64     `json:"size"`
+	ModTime    int64     `json:"mod_time"`
+	PrefixHash string    `json:"prefix_hash"`
+	Metadata   *Metadata `json:"metadata"`
+}
+
+// metadataCache is a JSON-backed cache of decoded photo metadata, keyed by
+// absolute path. Entries are validated against the file's current size,
+// mtime and a hash of its first 64KB before being trusted, so an edited
+// file is always re-decoded rather than served stale.
+//
+// Only the raw Metadata is cached, not the date/tags/slug derived from it:
+// those also depend on config.ExifToTags, the title template and any
+// sidecar file, none of which factor into the cache key above. Caching
+// them here would mean serving a stale tag set or slug after nothing but a
+// config edit, with no fingerprint mismatch to catch it. Deriving them
+// from the cached Metadata on every run is cheap enough that there's no
+// reason to take on that staleness risk.
+type metadataCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// loadMetadataCache reads path (creating an empty cache if it doesn't
+// exist yet or can't be parsed - a corrupt cache is never fatal, just
+// slower).
+func loadMetadataCache(path string) *metadataCache {
+	c := &metadataCache{path: path, entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+
+	var entries map[string]cacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return c
+	}
+	c.entries = entries
+
+	return c
+}
+
+// get returns the cached metadata for path if an entry exists and still
+// matches the file's current size, mtime and content-prefix hash.
+func (c *metadataCache) get(path string) (*Metadata, bool) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+
+	info, err := os.Stat(key)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().Unix() {
+		return nil, false
+	}
+
+	prefixHash, err := hashPrefix(key, cachePrefixBytes)
+	if err != nil || prefixHash != entry.PrefixHash {
+		return nil, false
+	}
+
+	return entry.Metadata, true
+}
+
+// put stores meta for path, fingerprinted by its current size, mtime and
+// content-prefix hash. Failures to stat/hash the file just mean this photo
+// won't be cached, not a fatal error for the caller.
+func (c *metadataCache) put(path string, meta *Metadata) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+
+	info, err := os.Stat(key)
+	if err != nil {
+		return
+	}
+
+	prefixHash, err := hashPrefix(key, cachePrefixBytes)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{
+		Size:       info.Size(),
+		ModTime:    info.ModTime().Unix(),
+		PrefixHash: prefixHash,
+		Metadata:   meta,
+	}
+	c.dirty = true
+}
+
+// clear empties the cache, used by --rebuild-cache.
+func (c *metadataCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+	c.dirty = true
+}
+
+// save writes the cache back to disk if anything changed since it was
+// loaded.
+func (c *metadataCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("error marshaling cache: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("error writing cache: %w", err)
+	}
+
+	return nil
+}
+
+// hashPrefix returns the sha1 of the first n bytes of path (or the whole
+// file, if it's smaller).
+func hashPrefix(path string, n int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.CopyN(h, f, n); err != nil && err != io.EOF {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "original content")
+
+	c := loadMetadataCache(filepath.Join(dir, "cache.json"))
+	meta := &Metadata{EXIF: map[string]string{"Model": "TestCam"}}
+	c.put(path, meta)
+
+	got, ok := c.get(path)
+	if !ok {
+		t.Fatal("get() = false after put(), want true")
+	}
+	if got.EXIF["Model"] != "TestCam" {
+		t.Errorf("cached Model = %q, want TestCam", got.EXIF["Model"])
+	}
+}
+
+func TestMetadataCacheMissWhenUncached(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "content")
+
+	c := loadMetadataCache(filepath.Join(dir, "cache.json"))
+	if _, ok := c.get(path); ok {
+		t.Error("get() = true for a path never put(), want false")
+	}
+}
+
+func TestMetadataCacheInvalidatesOnContentChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "original content")
+
+	c := loadMetadataCache(filepath.Join(dir, "cache.json"))
+	c.put(path, &Metadata{EXIF: map[string]string{"Model": "Stale"}})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := info.ModTime()
+
+	// Same size, different bytes, same mtime forced back - only the
+	// content-prefix hash can catch this.
+	writeTestFile(t, path, "changed content!")
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.get(path); ok {
+		t.Error("get() = true after content changed under an unchanged size/mtime, want false")
+	}
+}
+
+func TestMetadataCacheInvalidatesOnMTimeChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "content")
+
+	c := loadMetadataCache(filepath.Join(dir, "cache.json"))
+	c.put(path, &Metadata{EXIF: map[string]string{"Model": "Stale"}})
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.get(path); ok {
+		t.Error("get() = true after mtime changed, want false")
+	}
+}
+
+func TestMetadataCacheClear(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "content")
+
+	c := loadMetadataCache(filepath.Join(dir, "cache.json"))
+	c.put(path, &Metadata{EXIF: map[string]string{"Model": "X"}})
+	c.clear()
+
+	if _, ok := c.get(path); ok {
+		t.Error("get() = true after clear(), want false")
+	}
+}
+
+func TestMetadataCacheSaveAndReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	writeTestFile(t, path, "content")
+	cachePath := filepath.Join(dir, "cache.json")
+
+	c := loadMetadataCache(cachePath)
+	c.put(path, &Metadata{EXIF: map[string]string{"Model": "Persisted"}})
+	if err := c.save(); err != nil {
+		t.Fatalf("save(): %v", err)
+	}
+
+	reloaded := loadMetadataCache(cachePath)
+	got, ok := reloaded.get(path)
+	if !ok {
+		t.Fatal("get() = false after reloading a saved cache, want true")
+	}
+	if got.EXIF["Model"] != "Persisted" {
+		t.Errorf("cached Model = %q, want Persisted", got.EXIF["Model"])
+	}
+}
+
+func TestMetadataCacheSaveSkipsWhenNotDirty(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache.json")
+
+	c := loadMetadataCache(cachePath)
+	if err := c.save(); err != nil {
+		t.Fatalf("save(): %v", err)
+	}
+	if _, err := os.Stat(cachePath); err == nil {
+		t.Error("save() wrote a file for a clean cache with nothing to persist")
+	}
+}
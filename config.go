@@ -6,10 +6,71 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// StorageContentAddressed selects the content-addressed post layout, where
+// images are stored once under content/<xx>/<rest>.<ext> (keyed by the
+// first two hex chars of their hash) and posts reference that path instead
+// of holding their own copy.
+const StorageContentAddressed = "content-addressed"
+
 type Config struct {
 	ExifToTags map[string][]string `yaml:"exif_to_tags"`
 	PostsDir   string              `yaml:"posts_dir"`
-	Metadata   MetadataConfig      `yaml:"metadata"`
+	Storage    string              `yaml:"storage"`
+
+	// Stack groups related files (shared-basename RAW+JPEG pairs, or
+	// burst sequences close together in time on the same camera) into a
+	// single post instead of one post per file.
+	Stack                  bool     `yaml:"stack"`
+	StackWindowSeconds     int      `yaml:"stack_window_seconds"`
+	StackExtensionPriority []string `yaml:"stack_extensions"`
+
+	// Derivatives lists the resized images to generate for each photo.
+	// Empty uses defaultDerivatives, a single 800x800 thumbnail, matching
+	// the behavior before derivatives were configurable.
+	Derivatives []DerivativeSpec `yaml:"derivatives"`
+
+	// Tools configures the external binaries used to decode formats Go
+	// can't read natively (HEIC, RAW, video).
+	Tools ToolsConfig `yaml:"tools"`
+
+	Metadata MetadataConfig `yaml:"metadata"`
+}
+
+// ToolsConfig names the external binaries the decoder abstraction shells
+// out to for formats with no pure-Go decoder. Each defaults to its plain
+// name, so it's resolved from PATH unless overridden.
+type ToolsConfig struct {
+	HeifConvert string `yaml:"heif_convert"`
+	Dcraw       string `yaml:"dcraw"`
+	FFmpeg      string `yaml:"ffmpeg"`
+}
+
+// DerivativeSpec configures one resized output generated from a source
+// photo, e.g. a small WebP thumbnail and a larger JPEG preview.
+type DerivativeSpec struct {
+	Name string `yaml:"name"`
+
+	// Max fits the image inside a max x max square, same as the original
+	// hardcoded thumbnail behavior. Fit takes a "WxH" box instead, for
+	// non-square derivatives. Set at most one.
+	Max int    `yaml:"max"`
+	Fit string `yaml:"fit"`
+
+	// Filter is the resampling filter name (lanczos, box, linear, ...);
+	// defaults to lanczos.
+	Filter string `yaml:"filter"`
+
+	// Format is the output format (jpeg, png, webp); defaults to the
+	// source image's own format.
+	Format string `yaml:"format"`
+
+	// Quality is the JPEG encoding quality (1-100); defaults to 85 and is
+	// ignored for other formats.
+	Quality int `yaml:"quality"`
+
+	// PreserveExif copies the source's EXIF segment into a JPEG output,
+	// since resizing through image.Image otherwise discards it.
+	PreserveExif bool `yaml:"preserve_exif"`
 }
 
 type MetadataConfig struct {
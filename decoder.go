@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// heicExtensions are HEIF-family containers: imagemeta can read their EXIF
+// box directly (see imageFormatFor), but decoding pixel data needs an
+// external tool since neither image/* nor imaging understand HEIF.
+var heicExtensions = map[string]bool{"heic": true, "heif": true}
+
+// videoExtensions get a first-frame thumbnail and a duration field instead
+// of EXIF/IPTC/XMP metadata.
+var videoExtensions = map[string]bool{"mov": true, "mp4": true}
+
+func isVideoFile(path string) bool {
+	return videoExtensions[extOf(path)]
+}
+
+func extOf(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// decodeImageForThumbnail returns a decoded image.Image ready for resizing.
+// JPEG/PNG/TIFF/WebP decode the same way they always have, through
+// imaging.Open; HEIC, RAW and video need an external tool first because no
+// pure-Go decoder for them is wired in.
+func decodeImageForThumbnail(path string, tools ToolsConfig) (image.Image, error) {
+	ext := extOf(path)
+
+	switch {
+	case heicExtensions[ext]:
+		return decodeViaExternalTool(path, tools.binary(tools.HeifConvert, "heif-convert"), ".png", heifConvertArgs, false)
+	case rawExtensions[ext]:
+		return decodeViaExternalTool(path, tools.binary(tools.Dcraw, "dcraw"), ".tiff", dcrawArgs, true)
+	case videoExtensions[ext]:
+		return decodeViaExternalTool(path, tools.binary(tools.FFmpeg, "ffmpeg"), ".jpg", ffmpegFrameArgs, false)
+	default:
+		return imaging.Open(path)
+	}
+}
+
+// binary resolves a configured tool path, falling back to the plain binary
+// name so it's found on PATH if the config doesn't override it.
+func (t ToolsConfig) binary(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
+
+// argsBuilder builds the command-line arguments that make bin convert src
+// into the image written at dst.
+type argsBuilder func(src, dst string) []string
+
+func heifConvertArgs(src, dst string) []string {
+	return []string{src, dst}
+}
+
+// dcrawArgs asks dcraw to write a de-mosaiced, white-balanced TIFF to dst -
+// c writes to stdout (redirected to dst by the caller), T picks TIFF over
+// dcraw's default PPM so imaging.Open can read it back.
+func dcrawArgs(src, dst string) []string {
+	return []string{"-c", "-T", "-w", src}
+}
+
+func ffmpegFrameArgs(src, dst string) []string {
+	return []string{"-y", "-i", src, "-frames:v", "1", dst}
+}
+
+// decodeViaExternalTool shells out to bin to convert src into a format
+// imaging.Open can decode, via a temp file (named with tmpExt so tools that
+// infer their output format from the file extension, like heif-convert,
+// get the right one), and cleans up afterwards. writesToStdout is true for
+// tools (dcraw) whose conversion flags write the decoded image to stdout
+// rather than a named output path.
+func decodeViaExternalTool(src, bin, tmpExt string, buildArgs argsBuilder, writesToStdout bool) (image.Image, error) {
+	tmp, err := os.CreateTemp("", "bckt-photo-decode-*"+tmpExt)
+	if err != nil {
+		return nil, err
+	}
+	dst := tmp.Name()
+	tmp.Close()
+	defer os.Remove(dst)
+
+	cmd := exec.Command(bin, buildArgs(src, dst)...)
+
+	if writesToStdout {
+		out, err := os.Create(dst)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdout = out
+		err = cmd.Run()
+		out.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed decoding %s: %w", bin, src, err)
+		}
+	} else if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed decoding %s: %w", bin, src, err)
+	}
+
+	return imaging.Open(dst)
+}
+
+var ffmpegDurationRe = regexp.MustCompile(`Duration:\s*(\d+):(\d+):(\d+(?:\.\d+)?)`)
+
+// videoDuration runs ffmpeg against a video file and parses the duration
+// it reports on stderr (ffmpeg has no quiet "just tell me the duration"
+// mode without ffprobe, which we can't assume is installed separately).
+func videoDuration(path string, tools ToolsConfig) (time.Duration, error) {
+	bin := tools.binary(tools.FFmpeg, "ffmpeg")
+	cmd := exec.Command(bin, "-i", path)
+	output, _ := cmd.CombinedOutput() // ffmpeg -i with no output file always "fails"
+
+	match := ffmpegDurationRe.FindStringSubmatch(string(output))
+	if match == nil {
+		return 0, fmt.Errorf("could not find duration in %s output for %s", bin, path)
+	}
+
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), nil
+}
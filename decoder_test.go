@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeFixturePNG writes a tiny valid PNG to dir/name and returns its path,
+// standing in for whatever bytes a real external decode tool would produce.
+func writeFixturePNG(t *testing.T, dir, name string) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.White)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeShellScript creates an executable shell script at dir/name with the
+// given body, standing in for an external decode binary.
+func writeShellScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDecodeViaExternalToolNamedOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts as fake tools don't run on windows")
+	}
+
+	dir := t.TempDir()
+	fixture := writeFixturePNG(t, dir, "fixture.png")
+	// Mimics heif-convert: takes (src, dst) and writes the decoded image to
+	// the named dst path.
+	bin := writeShellScript(t, dir, "fake-heif-convert", `cp "`+fixture+`" "$2"`)
+
+	img, err := decodeViaExternalTool(filepath.Join(dir, "photo.heic"), bin, ".png", heifConvertArgs, false)
+	if err != nil {
+		t.Fatalf("decodeViaExternalTool: %v", err)
+	}
+	if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 2 {
+		t.Errorf("decoded bounds = %v, want 2x2", img.Bounds())
+	}
+}
+
+func TestDecodeViaExternalToolStdout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts as fake tools don't run on windows")
+	}
+
+	dir := t.TempDir()
+	fixture := writeFixturePNG(t, dir, "fixture.png")
+	// Mimics dcraw -c: ignores the dst argument and writes the decoded
+	// image to stdout instead.
+	bin := writeShellScript(t, dir, "fake-dcraw", `cat "`+fixture+`"`)
+
+	img, err := decodeViaExternalTool(filepath.Join(dir, "photo.cr2"), bin, ".png", dcrawArgs, true)
+	if err != nil {
+		t.Fatalf("decodeViaExternalTool: %v", err)
+	}
+	if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 2 {
+		t.Errorf("decoded bounds = %v, want 2x2", img.Bounds())
+	}
+}
+
+func TestDecodeViaExternalToolCommandFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts as fake tools don't run on windows")
+	}
+
+	dir := t.TempDir()
+	bin := writeShellScript(t, dir, "fake-failing-tool", `exit 1`)
+
+	if _, err := decodeViaExternalTool(filepath.Join(dir, "photo.heic"), bin, ".png", heifConvertArgs, false); err == nil {
+		t.Error("decodeViaExternalTool returned no error for a failing command, want one")
+	}
+}
@@ -3,62 +3,182 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
-	exif "github.com/dsoprea/go-exif/v3"
-	exifcommon "github.com/dsoprea/go-exif/v3/common"
+	"github.com/bep/imagemeta"
 )
 
-func readExifData(imagePath string) (*exif.Ifd, error) {
-	data, err := os.ReadFile(imagePath)
-	if err != nil {
-		return nil, err
+// Metadata is the normalized result of reading embedded EXIF, IPTC and XMP
+// data from an image in a single pass. Values are kept as their formatted
+// string representation, grouped under the source they came from, so that
+// templates and front matter can address any tag without us having to
+// hand-write a mapping for it up front.
+type Metadata struct {
+	EXIF map[string]string
+	IPTC map[string]string
+	XMP  map[string]string
+	GPS  GPSCoordinates
+}
+
+// GPSCoordinates is the normalized position read from the EXIF GPS IFD:
+// latitude/longitude already carry their N/S and E/W reference as a sign,
+// and altitude is in meters above (positive) or below (negative) sea level.
+type GPSCoordinates struct {
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	Altitude  float64 `yaml:"altitude"`
+	Valid     bool    `yaml:"-"`
+}
+
+// float64Valuer is satisfied by imagemeta's rational tag values, which keep
+// the numerator/denominator around instead of collapsing to a float64.
+type float64Valuer interface {
+	Float64() float64
+	String() string
+}
+
+// readExifData reads an image's EXIF/IPTC/XMP metadata, consulting
+// photoCache first so re-imports of an unchanged library don't pay for a
+// full metadata decode on every run.
+func readExifData(imagePath string) (*Metadata, error) {
+	if photoCache != nil {
+		if meta, ok := photoCache.get(imagePath); ok {
+			return meta, nil
+		}
 	}
 
-	rawExif, err := exif.SearchAndExtractExif(data)
+	meta, err := decodeExifData(imagePath)
 	if err != nil {
 		return nil, err
 	}
 
-	im, err := exifcommon.NewIfdMappingWithStandard()
+	if photoCache != nil {
+		photoCache.put(imagePath, meta)
+	}
+
+	return meta, nil
+}
+
+func decodeExifData(imagePath string) (*Metadata, error) {
+	f, err := os.Open(imagePath)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
+
+	meta := &Metadata{
+		EXIF: make(map[string]string),
+		IPTC: make(map[string]string),
+		XMP:  make(map[string]string),
+	}
+
+	rawGPS := make(map[string]any)
 
-	ti := exif.NewTagIndex()
+	_, err = imagemeta.Decode(imagemeta.Options{
+		R:           f,
+		ImageFormat: imageFormatFor(imagePath),
+		Sources:     imagemeta.EXIF | imagemeta.IPTC | imagemeta.XMP,
+		HandleTag: func(info imagemeta.TagInfo) error {
+			if info.Source == imagemeta.EXIF && strings.HasPrefix(info.Tag, "GPS") {
+				rawGPS[info.Tag] = info.Value
+			}
+
+			value := formatTagValue(info.Value)
+			if value == "" {
+				return nil
+			}
+
+			switch info.Source {
+			case imagemeta.EXIF:
+				meta.EXIF[info.Tag] = value
+			case imagemeta.IPTC:
+				meta.IPTC[info.Tag] = value
+			case imagemeta.XMP:
+				meta.XMP[info.Tag] = value
+			}
 
-	_, index, err := exif.Collect(im, ti, rawExif)
+			return nil
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return index.RootIfd, nil
+	meta.GPS = resolveGPS(rawGPS)
+
+	return meta, nil
 }
 
-func extractDate(ifd *exif.Ifd) time.Time {
-	if ifd == nil {
-		return time.Now()
+// imageFormatFor maps a file extension to the image format imagemeta needs
+// to be told up front, since format auto-detection isn't implemented yet.
+// RAW formats (CR2/NEF/ARW/DNG/...) are TIFF at the container level - their
+// maker-specific image data lives in private IFDs imagemeta doesn't touch,
+// but the standard EXIF IFD with capture info decodes the same as any
+// other TIFF.
+func imageFormatFor(path string) imagemeta.ImageFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return imagemeta.JPEG
+	case ".tif", ".tiff", ".cr2", ".nef", ".arw", ".dng", ".raf", ".orf", ".rw2", ".pef", ".srw":
+		return imagemeta.TIFF
+	case ".png":
+		return imagemeta.PNG
+	case ".webp":
+		return imagemeta.WebP
+	case ".heic", ".heif":
+		return imagemeta.HEIF
+	default:
+		return imagemeta.JPEG
 	}
+}
 
-	// Try to get DateTime
-	results, err := ifd.FindTagWithName("DateTime")
-	if err != nil || len(results) == 0 {
-		// Try DateTimeOriginal as fallback
-		results, err = ifd.FindTagWithName("DateTimeOriginal")
-		if err != nil || len(results) == 0 {
-			return time.Now()
+// resolveGPS turns the raw GPSLatitude/GPSLongitude/GPSAltitude tags (plus
+// their *Ref companions) into signed decimal degrees and meters.
+func resolveGPS(raw map[string]any) GPSCoordinates {
+	lat, latOK := signedDegrees(raw["GPSLatitude"], raw["GPSLatitudeRef"], "S")
+	lon, lonOK := signedDegrees(raw["GPSLongitude"], raw["GPSLongitudeRef"], "W")
+	if !latOK || !lonOK {
+		return GPSCoordinates{}
+	}
+
+	gps := GPSCoordinates{Latitude: lat, Longitude: lon, Valid: true}
+
+	if alt, ok := raw["GPSAltitude"].(float64Valuer); ok {
+		gps.Altitude = alt.Float64()
+		if ref := fmt.Sprintf("%v", raw["GPSAltitudeRef"]); ref == "1" {
+			gps.Altitude = -gps.Altitude
 		}
 	}
 
-	ite := results[0]
-	valueRaw, err := ite.Value()
-	if err != nil {
+	return gps
+}
+
+func signedDegrees(value, ref any, negativeRef string) (float64, bool) {
+	deg, ok := value.(float64)
+	if !ok {
+		return 0, false
+	}
+
+	if r, ok := ref.(string); ok && r == negativeRef {
+		deg = -deg
+	}
+
+	return deg, true
+}
+
+func extractDate(meta *Metadata) time.Time {
+	if meta == nil {
 		return time.Now()
 	}
 
-	// Parse EXIF datetime format: "2006:01:02 15:04:05"
-	dateStr, ok := valueRaw.(string)
-	if !ok {
+	dateStr := meta.EXIF["DateTime"]
+	if dateStr == "" {
+		dateStr = meta.EXIF["DateTimeOriginal"]
+	}
+	if dateStr == "" {
 		return time.Now()
 	}
 
@@ -70,18 +190,22 @@ func extractDate(ifd *exif.Ifd) time.Time {
 	return dt
 }
 
-func extractTags(ifd *exif.Ifd, exifToTags map[string][]string) []string {
-	if ifd == nil || len(exifToTags) == 0 {
+func extractTags(meta *Metadata, exifToTags map[string][]string) []string {
+	if meta == nil {
 		return nil
 	}
 
 	// Use a map to deduplicate tags
 	tagSet := make(map[string]bool)
 
+	for _, keyword := range splitMultiValue(meta.IPTC["Keywords"]) {
+		tagSet[keyword] = true
+	}
+
 	for fieldName, exifFields := range exifToTags {
-		// Try each EXIF field in priority order
+		// Try each field in priority order
 		for _, exifField := range exifFields {
-			value := findExifValue(ifd, exifField)
+			value := findMetadataValue(meta, exifField)
 			if value != "" {
 				// Use friendly format for tags if available
 				friendly := formatFriendlyValue(fieldName, value)
@@ -95,6 +219,10 @@ func extractTags(ifd *exif.Ifd, exifToTags map[string][]string) []string {
 		}
 	}
 
+	if len(tagSet) == 0 {
+		return nil
+	}
+
 	// Convert set to slice
 	var tags []string
 	for tag := range tagSet {
@@ -104,16 +232,46 @@ func extractTags(ifd *exif.Ifd, exifToTags map[string][]string) []string {
 	return tags
 }
 
-func extractExifFields(ifd *exif.Ifd, exifToTags map[string][]string) map[string]interface{} {
-	if ifd == nil || len(exifToTags) == 0 {
+// extractExifFields returns the front matter metadata for a photo: the
+// auto-extracted EXIF/IPTC/XMP submaps and normalized GPS coordinates, plus
+// exif_to_tags as an overlay on top, picking specific values into the
+// user-chosen field names it has always used.
+func extractExifFields(meta *Metadata, exifToTags map[string][]string) map[string]interface{} {
+	if meta == nil {
 		return nil
 	}
 
 	fields := make(map[string]interface{})
+
+	if len(meta.EXIF) > 0 {
+		fields["exif"] = meta.EXIF
+	}
+	if len(meta.IPTC) > 0 {
+		fields["iptc"] = meta.IPTC
+	}
+	if len(meta.XMP) > 0 {
+		fields["xmp"] = meta.XMP
+	}
+	if meta.GPS.Valid {
+		fields["gps"] = meta.GPS
+	}
+	if caption := firstMetadataValue(meta, "Caption-Abstract", "description"); caption != "" {
+		fields["caption"] = caption
+	}
+	if headline := firstMetadataValue(meta, "Headline", "title"); headline != "" {
+		fields["headline"] = headline
+	}
+	if copyright := firstMetadataValue(meta, "CopyrightNotice", "rights"); copyright != "" {
+		fields["copyright"] = copyright
+	}
+	if rating := firstMetadataValue(meta, "Rating"); rating != "" {
+		fields["rating"] = rating
+	}
+
 	for fieldName, exifFields := range exifToTags {
-		// Try each EXIF field in priority order until we find a value
+		// Try each field in priority order until we find a value
 		for _, exifField := range exifFields {
-			value := findExifValue(ifd, exifField)
+			value := findMetadataValue(meta, exifField)
 			if value != "" {
 				fields[fieldName] = value
 
@@ -130,80 +288,73 @@ func extractExifFields(ifd *exif.Ifd, exifToTags map[string][]string) map[string
 	return fields
 }
 
-// findExifValue searches for an EXIF tag by name recursively through all IFDs
-func findExifValue(ifd *exif.Ifd, tagName string) string {
-	if ifd == nil {
+// findMetadataValue looks up a tag name across EXIF, then IPTC, then XMP.
+func findMetadataValue(meta *Metadata, tagName string) string {
+	if meta == nil {
 		return ""
 	}
+	if v, ok := meta.EXIF[tagName]; ok {
+		return v
+	}
+	if v, ok := meta.IPTC[tagName]; ok {
+		return v
+	}
+	if v, ok := meta.XMP[tagName]; ok {
+		return v
+	}
+	return ""
+}
 
-	var foundValue string
-	err := ifd.EnumerateTagsRecursively(func(i *exif.Ifd, ite *exif.IfdTagEntry) error {
-		if ite.TagName() == tagName {
-			valueRaw, err := ite.Value()
-			if err == nil {
-				foundValue = formatExifValue(valueRaw)
-				if foundValue != "" {
-					return fmt.Errorf("found") // Stop enumeration
-				}
-			}
+func firstMetadataValue(meta *Metadata, tagNames ...string) string {
+	for _, tagName := range tagNames {
+		if v := findMetadataValue(meta, tagName); v != "" {
+			return v
 		}
+	}
+	return ""
+}
+
+func splitMultiValue(value string) []string {
+	if value == "" {
 		return nil
-	})
+	}
 
-	// Ignore the "found" error we use to stop enumeration
-	if err != nil && err.Error() != "found" {
-		return ""
+	parts := strings.Split(value, ", ")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
 	}
 
-	return foundValue
+	return result
 }
 
-func formatExifValue(valueRaw interface{}) string {
-	// Handle different types of EXIF values
+// formatTagValue renders an imagemeta tag value (string, number, rational,
+// or a slice of those) the same way a human would expect to read it.
+func formatTagValue(valueRaw interface{}) string {
 	switch v := valueRaw.(type) {
-	case []uint16:
-		// Common for ISO, handle uint16 arrays
-		if len(v) > 0 {
-			return fmt.Sprintf("%d", v[0])
-		}
-	case []int:
-		// Handle int arrays
-		if len(v) > 0 {
-			return fmt.Sprintf("%d", v[0])
-		}
-	case []string:
-		// Handle string arrays
-		if len(v) > 0 {
-			return v[0]
-		}
+	case nil:
+		return ""
 	case string:
-		// Simple string value
 		return v
-	case []exifcommon.Rational:
-		// Handle rational numbers (fractions) - common for aperture, exposure, focal length
-		if len(v) > 0 {
-			rational := v[0]
-			if rational.Denominator == 0 {
-				return ""
-			}
-			// Return as fraction
-			return fmt.Sprintf("%d/%d", rational.Numerator, rational.Denominator)
-		}
-	case []exifcommon.SignedRational:
-		// Handle signed rational numbers
-		if len(v) > 0 {
-			rational := v[0]
-			if rational.Denominator == 0 {
-				return ""
+	case []string:
+		return strings.Join(v, ", ")
+	case float64Valuer:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := formatTagValue(item); s != "" {
+				parts = append(parts, s)
 			}
-			// Return as fraction
-			return fmt.Sprintf("%d/%d", rational.Numerator, rational.Denominator)
 		}
+		return strings.Join(parts, ", ")
 	default:
-		// For other types, use default formatting
-		return fmt.Sprintf("%v", valueRaw)
+		return fmt.Sprintf("%v", v)
 	}
-	return ""
 }
 
 // formatFriendlyValue formats values in user-friendly format for specific field types
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/disintegration/imaging"
 )
 
@@ -27,23 +38,251 @@ func copyFile(src, dst string) error {
 	return destFile.Sync()
 }
 
-func createThumbnail(src, dst string, maxWidth, maxHeight int) error {
-	// Open the image
-	img, err := imaging.Open(src)
+// hashFile returns the sha256 of a file's contents, used as the content
+// address for the content-addressed storage layout.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contentAddressedRelPath returns the path of a hashed file relative to
+// postsDir: content/<xx>/<rest>.<ext>, where xx is the first two hex chars
+// of the hash. Keeping the fan-out directory shallow avoids dumping
+// thousands of files into one directory on large imports.
+func contentAddressedRelPath(hash, ext string) string {
+	return filepath.Join("content", hash[:2], hash[2:]+ext)
+}
+
+// defaultDerivatives is used when a config has no derivatives configured,
+// preserving the original hardcoded single 800x800 thumbnail behavior.
+var defaultDerivatives = []DerivativeSpec{
+	{Name: "thumb", Max: 800},
+}
+
+var resampleFilters = map[string]imaging.ResampleFilter{
+	"lanczos": imaging.Lanczos,
+	"box":     imaging.Box,
+	"linear":  imaging.Linear,
+	"cubic":   imaging.CatmullRom,
+	"nearest": imaging.NearestNeighbor,
+}
+
+// encodableFormats are the formats generateDerivative can actually encode.
+var encodableFormats = map[string]bool{"jpeg": true, "png": true, "webp": true}
+
+// resolveDerivativeFormat decides which format a derivative is actually
+// encoded in: spec.Format if it's one we can encode, otherwise the source's
+// own extension if that happens to be encodable, otherwise JPEG (covers
+// HEIC, RAW and video sources, which we can only decode, not re-encode in
+// their own format). derivativeExt and generateDerivative both resolve the
+// format through this function, so a derivative's file extension always
+// matches the bytes actually written to it.
+func resolveDerivativeFormat(spec DerivativeSpec, srcExt string) string {
+	if format := normalizeFormat(spec.Format); encodableFormats[format] {
+		return format
+	}
+	if format := normalizeFormat(srcExt); encodableFormats[format] {
+		return format
+	}
+	return "jpeg"
+}
+
+// normalizeFormat lowercases an extension or format name and maps "jpg" to
+// "jpeg", the encoders'/encodableFormats' canonical spelling.
+func normalizeFormat(s string) string {
+	s = strings.ToLower(strings.TrimPrefix(s, "."))
+	if s == "jpg" {
+		s = "jpeg"
+	}
+	return s
+}
+
+// derivativeExt returns the file extension a derivative should be saved
+// with, matching whatever format resolveDerivativeFormat picks for it.
+func derivativeExt(spec DerivativeSpec, srcExt string) string {
+	switch resolveDerivativeFormat(spec, srcExt) {
+	case "png":
+		return ".png"
+	case "webp":
+		return ".webp"
+	default:
+		return ".jpg"
+	}
+}
+
+// generateDerivative resizes src per spec (a max square or a WxH fit box,
+// skipping the resize if src is already smaller in every dimension) and
+// saves it to dst in spec.Format, optionally preserving the source's EXIF
+// segment in a JPEG output.
+func generateDerivative(src, dst string, spec DerivativeSpec, tools ToolsConfig) error {
+	img, err := decodeImageForThumbnail(src, tools)
+	if err != nil {
+		return err
+	}
+
+	targetW, targetH, err := spec.targetBox()
 	if err != nil {
 		return err
 	}
 
-	// Get current dimensions
 	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
+	if bounds.Dx() > targetW || bounds.Dy() > targetH {
+		filter, ok := resampleFilters[strings.ToLower(spec.Filter)]
+		if !ok {
+			filter = imaging.Lanczos
+		}
+		img = imaging.Fit(img, targetW, targetH, filter)
+	}
+
+	switch resolveDerivativeFormat(spec, filepath.Ext(src)) {
+	case "webp":
+		return encodeWebP(img, dst)
+	case "png":
+		return encodePNG(img, dst)
+	default:
+		return encodeJPEG(img, dst, spec, src)
+	}
+}
+
+// targetBox resolves a DerivativeSpec's resize target to a width and
+// height: Fit ("WxH") if set, otherwise a Max x Max square.
+func (spec DerivativeSpec) targetBox() (int, int, error) {
+	if spec.Fit != "" {
+		parts := strings.SplitN(strings.ToLower(spec.Fit), "x", 2)
+		if len(parts) != 2 {
+			return 0, 0, fmt.Errorf("invalid fit %q, expected WxH", spec.Fit)
+		}
+		w, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid fit width %q: %w", spec.Fit, err)
+		}
+		h, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid fit height %q: %w", spec.Fit, err)
+		}
+		return w, h, nil
+	}
+
+	max := spec.Max
+	if max == 0 {
+		max = 800
+	}
+	return max, max, nil
+}
+
+func encodePNG(img image.Image, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return png.Encode(f, img)
+}
+
+func encodeWebP(img image.Image, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return nativewebp.Encode(f, img, nil)
+}
 
-	// Only resize if image is larger than max dimensions
-	if width > maxWidth || height > maxHeight {
-		img = imaging.Fit(img, maxWidth, maxHeight, imaging.Lanczos)
+func encodeJPEG(img image.Image, dst string, spec DerivativeSpec, src string) error {
+	quality := spec.Quality
+	if quality == 0 {
+		quality = 85
 	}
 
-	// Save the thumbnail
-	return imaging.Save(img, dst)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	if spec.PreserveExif {
+		if segment, err := readEXIFSegment(src); err == nil {
+			data = withEXIFSegment(data, segment)
+		}
+	}
+
+	return os.WriteFile(dst, data, 0644)
+}
+
+// jpegSOIMarker and jpegAPP1Marker are the JPEG start-of-image and "app
+// segment 1" markers; EXIF is stored in the first APP1 segment whose
+// payload starts with the "Exif\x00\x00" identifier.
+var (
+	jpegSOIMarker  = []byte{0xFF, 0xD8}
+	jpegAPP1Marker = byte(0xE1)
+	exifIdentifier = []byte("Exif\x00\x00")
+)
+
+// readEXIFSegment extracts the raw APP1/EXIF marker segment (marker + length
+// + payload) from a source JPEG, so it can be reinserted into a re-encoded
+// copy that would otherwise lose it.
+func readEXIFSegment(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 || data[0] != jpegSOIMarker[0] || data[1] != jpegSOIMarker[1] {
+		return nil, fmt.Errorf("%s is not a JPEG", path)
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("malformed JPEG marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xD8 || marker == 0xD9 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos += 2
+			continue
+		}
+
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		segmentEnd := pos + 2 + length
+		if segmentEnd > len(data) {
+			break
+		}
+
+		if marker == jpegAPP1Marker {
+			payload := data[pos+4 : segmentEnd]
+			if bytes.HasPrefix(payload, exifIdentifier) {
+				return data[pos:segmentEnd], nil
+			}
+		}
+
+		if marker == 0xDA {
+			// Start of scan - no more marker segments follow.
+			break
+		}
+
+		pos = segmentEnd
+	}
+
+	return nil, fmt.Errorf("no EXIF segment found in %s", path)
+}
+
+// withEXIFSegment inserts a previously-read APP1/EXIF segment into a JPEG
+// right after its SOI marker.
+func withEXIFSegment(jpegData, segment []byte) []byte {
+	out := make([]byte, 0, len(jpegData)+len(segment))
+	out = append(out, jpegData[:2]...)
+	out = append(out, segment...)
+	out = append(out, jpegData[2:]...)
+	return out
 }
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestResolveDerivativeFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		srcExt string
+		want   string
+	}{
+		{"explicit webp wins over source", "webp", ".jpg", "webp"},
+		{"explicit jpg normalizes to jpeg", "jpg", ".png", "jpeg"},
+		{"no format falls back to encodable source ext", "", ".png", "png"},
+		{"no format, non-encodable source falls back to jpeg", "", ".cr2", "jpeg"},
+		{"no format, heic source falls back to jpeg", "", ".HEIC", "jpeg"},
+		{"no format, video source falls back to jpeg", "", ".mp4", "jpeg"},
+		{"unrecognized explicit format falls back through source ext", "tiff", ".png", "png"},
+	}
+
+	for _, c := range cases {
+		spec := DerivativeSpec{Format: c.format}
+		if got := resolveDerivativeFormat(spec, c.srcExt); got != c.want {
+			t.Errorf("%s: resolveDerivativeFormat(%q, %q) = %q, want %q", c.name, c.format, c.srcExt, got, c.want)
+		}
+	}
+}
+
+// TestDerivativeExtMatchesResolvedFormat is the regression test for the
+// bug where derivativeExt and generateDerivative disagreed on a RAW/HEIC/
+// video source with no explicit spec.Format: derivativeExt kept the
+// source's own extension while generateDerivative actually encoded JPEG.
+func TestDerivativeExtMatchesResolvedFormat(t *testing.T) {
+	cases := []struct {
+		spec   DerivativeSpec
+		srcExt string
+		want   string
+	}{
+		{DerivativeSpec{Name: "thumb", Max: 800}, ".CR2", ".jpg"},
+		{DerivativeSpec{Name: "thumb", Max: 800}, ".heic", ".jpg"},
+		{DerivativeSpec{Name: "thumb", Max: 800}, ".mp4", ".jpg"},
+		{DerivativeSpec{Name: "thumb", Max: 800}, ".png", ".png"},
+		{DerivativeSpec{Name: "small", Format: "webp"}, ".cr2", ".webp"},
+	}
+
+	for _, c := range cases {
+		if got := derivativeExt(c.spec, c.srcExt); got != c.want {
+			t.Errorf("derivativeExt(%+v, %q) = %q, want %q", c.spec, c.srcExt, got, c.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 
 	"github.com/spf13/cobra"
 )
@@ -14,12 +16,16 @@ var (
 	date    = "unknown"
 
 	// CLI flags
-	imageFile  string
-	title      string
-	configFile string
-	postsDir   string
-	language   string
-	extraTags  []string
+	imageFile    string
+	title        string
+	configFile   string
+	postsDir     string
+	language     string
+	extraTags    []string
+	jobs         int
+	stack        bool
+	noCache      bool
+	rebuildCache bool
 )
 
 var rootCmd = &cobra.Command{
@@ -37,6 +43,10 @@ func init() {
 	rootCmd.Flags().StringVarP(&postsDir, "posts", "p", "posts", "Posts directory")
 	rootCmd.Flags().StringVarP(&language, "lang", "l", "en", "Post language")
 	rootCmd.Flags().StringSliceVarP(&extraTags, "tags", "g", []string{}, "Tags (literal or templates with @keywords, comma-separated or multiple flags)")
+	rootCmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Number of concurrent workers when processing a directory")
+	rootCmd.Flags().BoolVar(&stack, "stack", false, "Group RAW+JPEG pairs and burst sequences into a single post")
+	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Don't read or write the metadata cache")
+	rootCmd.Flags().BoolVar(&rebuildCache, "rebuild-cache", false, "Discard the existing metadata cache and rebuild it from scratch")
 	rootCmd.MarkFlagRequired("image")
 
 	// Custom version template
@@ -64,6 +74,24 @@ func run(cmd *cobra.Command, args []string) error {
 		postsDir = config.PostsDir
 	}
 
+	// --stack only turns stacking on; config.yaml remains the way to enable
+	// it by default for a given site.
+	if stack {
+		config.Stack = true
+	}
+
+	if !noCache {
+		photoCache = loadMetadataCache(filepath.Join(cacheDir, cacheFile))
+		if rebuildCache {
+			photoCache.clear()
+		}
+		defer func() {
+			if err := photoCache.save(); err != nil {
+				fmt.Printf("Warning: Could not save metadata cache: %v\n", err)
+			}
+		}()
+	}
+
 	// Check if input is a directory
 	fileInfo, err := os.Stat(imageFile)
 	if err != nil {
@@ -72,7 +100,7 @@ func run(cmd *cobra.Command, args []string) error {
 
 	if fileInfo.IsDir() {
 		// Process directory
-		return processDirectory(imageFile, config, title, extraTags)
+		return processDirectory(imageFile, config, title, extraTags, jobs)
 	}
 
 	// Process single file
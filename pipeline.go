@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipelineSource is a single image found by the walk stage, queued up for
+// parsing.
+type pipelineSource struct {
+	imagePath   string
+	relativeDir string
+}
+
+// pipelineStatus is the outcome of processing one photo through the
+// pipeline, used to keep the end-of-run summary.
+type pipelineStatus int
+
+const (
+	pipelineInfo pipelineStatus = iota
+	pipelineProcessed
+	pipelineSkipped
+	pipelineFailed
+)
+
+// pipelineOutcome is sent to the printer goroutine so all directory
+// processing output is written to stdout from one place, regardless of how
+// many parse/write workers are running concurrently.
+type pipelineOutcome struct {
+	status  pipelineStatus
+	message string
+}
+
+// processDirectory walks baseDir and processes every image it finds through
+// a three-stage pipeline: a source stage walks the tree and emits paths, a
+// parse stage reads EXIF data and resolves title/slug/tags for each photo,
+// and a write stage creates directories, copies files, and writes
+// thumbnails and markdown. The parse and write stages share a pool of
+// `jobs` workers (default runtime.NumCPU()), so one photo's thumbnail
+// generation doesn't block another's EXIF decoding. A single printer
+// goroutine owns stdout so output from concurrent workers stays coherent -
+// lines are never interleaved mid-message - but workers finish in
+// whatever order their own decode/write work completes, so printed lines
+// are NOT guaranteed to follow the walk's source order.
+func processDirectory(baseDir string, config *Config, title string, extraTags []string, jobs int) error {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	if config.Stack {
+		return processDirectoryStacked(baseDir, config, title, extraTags, jobs)
+	}
+
+	fmt.Printf("Processing directory: %s (jobs=%d)\n", baseDir, jobs)
+
+	sources := make(chan pipelineSource)
+	outcomes := make(chan pipelineOutcome)
+
+	var processed, skipped, failed int
+	printerDone := make(chan struct{})
+	go func() {
+		defer close(printerDone)
+		processed, skipped, failed = runPrinter(outcomes)
+	}()
+
+	var workersWG sync.WaitGroup
+	workersWG.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer workersWG.Done()
+			for src := range sources {
+				processPipelineSource(src, config, title, extraTags, outcomes)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(sources)
+		err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error accessing %s: %v", path, err)}
+				return nil
+			}
+
+			if info.IsDir() || !isImageFile(path) {
+				return nil
+			}
+
+			relDir, err := sourceRelDir(baseDir, path)
+			if err != nil {
+				outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error calculating relative path for %s: %v", path, err)}
+				return nil
+			}
+
+			sources <- pipelineSource{imagePath: path, relativeDir: relDir}
+			return nil
+		})
+		if err != nil {
+			outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error walking directory: %v", err)}
+		}
+	}()
+
+	workersWG.Wait()
+	close(outcomes)
+	<-printerDone
+
+	fmt.Printf("Directory processing complete: %d processed, %d skipped, %d failed\n", processed, skipped, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d photo(s) failed to process", failed)
+	}
+
+	return nil
+}
+
+// sourceRelDir returns path's directory relative to baseDir, normalized to
+// "" for baseDir's own top level so extractPathComponents doesn't have to
+// special-case a "." relative directory.
+func sourceRelDir(baseDir, path string) (string, error) {
+	relPath, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		return "", err
+	}
+
+	relDir := filepath.Dir(relPath)
+	if relDir == "." {
+		relDir = ""
+	}
+
+	return relDir, nil
+}
+
+// runPrinter drains outcomes, printing each message and tallying the
+// processed/skipped/failed counts for the end-of-run summary. It is the
+// only thing that writes to stdout, so it's what keeps output coherent
+// across however many parse/write workers are running; it returns once
+// outcomes is closed.
+func runPrinter(outcomes <-chan pipelineOutcome) (processed, skipped, failed int) {
+	for outcome := range outcomes {
+		switch outcome.status {
+		case pipelineProcessed:
+			processed++
+		case pipelineSkipped:
+			skipped++
+		case pipelineFailed:
+			failed++
+		}
+		fmt.Println(outcome.message)
+	}
+
+	return processed, skipped, failed
+}
+
+// processPipelineSource runs one photo through the parse and write stages,
+// sending every status line to outcomes so the printer goroutine is the
+// only thing writing to stdout. Errors become a failed outcome rather than
+// being propagated, so one bad photo doesn't stop its siblings.
+func processPipelineSource(src pipelineSource, config *Config, title string, extraTags []string, outcomes chan<- pipelineOutcome) {
+	job, err := buildPhotoJob(src.imagePath, src.relativeDir, config, title, extraTags)
+	if err != nil {
+		outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error processing %s: %v", src.imagePath, err)}
+		return
+	}
+	for _, warning := range job.warnings {
+		outcomes <- pipelineOutcome{status: pipelineInfo, message: warning}
+	}
+
+	postDir, err := writePhotoJob(job)
+	if err != nil {
+		if errors.Is(err, errAlreadyImported) {
+			outcomes <- pipelineOutcome{status: pipelineSkipped, message: fmt.Sprintf("Skipping %s: %v", filepath.Base(src.imagePath), err)}
+			return
+		}
+		outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error processing %s: %v", src.imagePath, err)}
+		return
+	}
+
+	outcomes <- pipelineOutcome{status: pipelineProcessed, message: fmt.Sprintf("Post created successfully at: %s", postDir)}
+}
+
+// defaultStackWindow is how close together (in time, same camera) ungrouped
+// files must be to be treated as one burst, when the config doesn't set
+// stack_window_seconds.
+const defaultStackWindow = 2 * time.Second
+
+// processDirectoryStacked is processDirectory's counterpart for
+// config.Stack: it can't start writing posts until it has seen every file,
+// since a RAW sibling or a later burst frame might appear anywhere later in
+// the walk. It walks baseDir and reads capture info for every image (worker
+// pool), groups them into stacks, then writes one post per stack (worker
+// pool again), with a single printer goroutine owning stdout throughout -
+// the same "coherent, not ordered" guarantee as processDirectory.
+func processDirectoryStacked(baseDir string, config *Config, title string, extraTags []string, jobs int) error {
+	fmt.Printf("Processing directory: %s (jobs=%d, stacking enabled)\n", baseDir, jobs)
+
+	var sources []pipelineSource
+	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing %s: %w", path, err)
+		}
+		if info.IsDir() || !isImageFile(path) {
+			return nil
+		}
+
+		relDir, err := sourceRelDir(baseDir, path)
+		if err != nil {
+			return fmt.Errorf("error calculating relative path for %s: %w", path, err)
+		}
+
+		sources = append(sources, pipelineSource{imagePath: path, relativeDir: relDir})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error walking directory: %w", err)
+	}
+
+	members := make([]stackMember, len(sources))
+	sourceCh := make(chan int)
+	var readersWG sync.WaitGroup
+	readersWG.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer readersWG.Done()
+			for idx := range sourceCh {
+				src := sources[idx]
+				capturedAt, camera := readCaptureInfo(src.imagePath)
+				base := filepath.Base(src.imagePath)
+				ext := filepath.Ext(base)
+				members[idx] = stackMember{
+					path:       src.imagePath,
+					relDir:     src.relativeDir,
+					basename:   strings.TrimSuffix(base, ext),
+					ext:        ext,
+					capturedAt: capturedAt,
+					camera:     camera,
+				}
+			}
+		}()
+	}
+	for i := range sources {
+		sourceCh <- i
+	}
+	close(sourceCh)
+	readersWG.Wait()
+
+	window := defaultStackWindow
+	if config.StackWindowSeconds > 0 {
+		window = time.Duration(config.StackWindowSeconds) * time.Second
+	}
+	groups := groupStacks(members, window, config.StackExtensionPriority)
+
+	outcomes := make(chan pipelineOutcome)
+	var processed, skipped, failed int
+	printerDone := make(chan struct{})
+	go func() {
+		defer close(printerDone)
+		processed, skipped, failed = runPrinter(outcomes)
+	}()
+
+	groupCh := make(chan stackGroup)
+	var workersWG sync.WaitGroup
+	workersWG.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		go func() {
+			defer workersWG.Done()
+			for group := range groupCh {
+				processStackGroup(group, config, title, extraTags, outcomes)
+			}
+		}()
+	}
+	for _, group := range groups {
+		groupCh <- group
+	}
+	close(groupCh)
+
+	workersWG.Wait()
+	close(outcomes)
+	<-printerDone
+
+	fmt.Printf("Directory processing complete: %d processed, %d skipped, %d failed\n", processed, skipped, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d photo(s) failed to process", failed)
+	}
+
+	return nil
+}
+
+// processStackGroup runs one stack (its primary file plus any stacked
+// siblings) through the parse and write stages, the same way
+// processPipelineSource does for a single file.
+func processStackGroup(group stackGroup, config *Config, title string, extraTags []string, outcomes chan<- pipelineOutcome) {
+	primary := group.primary
+	job, err := buildPhotoJob(primary.path, primary.relDir, config, title, extraTags)
+	if err != nil {
+		outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error processing %s: %v", primary.path, err)}
+		return
+	}
+	for _, warning := range job.warnings {
+		outcomes <- pipelineOutcome{status: pipelineInfo, message: warning}
+	}
+	job.stackMembers = group.members[1:]
+
+	postDir, err := writePhotoJob(job)
+	if err != nil {
+		if errors.Is(err, errAlreadyImported) {
+			outcomes <- pipelineOutcome{status: pipelineSkipped, message: fmt.Sprintf("Skipping %s: %v", filepath.Base(primary.path), err)}
+			return
+		}
+		outcomes <- pipelineOutcome{status: pipelineFailed, message: fmt.Sprintf("Error processing %s: %v", primary.path, err)}
+		return
+	}
+
+	outcomes <- pipelineOutcome{status: pipelineProcessed, message: fmt.Sprintf("Post created successfully at: %s", postDir)}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestSourceRelDir(t *testing.T) {
+	cases := []struct {
+		baseDir string
+		path    string
+		want    string
+	}{
+		{"/library", "/library/photo.jpg", ""},
+		{"/library", "/library/2026/vacation/photo.jpg", "2026/vacation"},
+		{"/library", "/library/2026/photo.jpg", "2026"},
+	}
+
+	for _, c := range cases {
+		got, err := sourceRelDir(c.baseDir, c.path)
+		if err != nil {
+			t.Fatalf("sourceRelDir(%q, %q): %v", c.baseDir, c.path, err)
+		}
+		if got != c.want {
+			t.Errorf("sourceRelDir(%q, %q) = %q, want %q", c.baseDir, c.path, got, c.want)
+		}
+	}
+}
+
+// TestRunPrinterTalliesRegardlessOfArrivalOrder exercises the worker pool's
+// tallying logic with outcomes arriving in an order other than how they'd
+// be produced by a source walk - workers finish whenever their own
+// decode/write work completes, so runPrinter's counts must not depend on
+// outcome order, only on each outcome's status.
+func TestRunPrinterTalliesRegardlessOfArrivalOrder(t *testing.T) {
+	outcomes := make(chan pipelineOutcome, 5)
+	outcomes <- pipelineOutcome{status: pipelineFailed, message: "third file failed"}
+	outcomes <- pipelineOutcome{status: pipelineProcessed, message: "first file ok"}
+	outcomes <- pipelineOutcome{status: pipelineSkipped, message: "second file skipped"}
+	outcomes <- pipelineOutcome{status: pipelineProcessed, message: "fourth file ok"}
+	outcomes <- pipelineOutcome{status: pipelineInfo, message: "just a warning"}
+	close(outcomes)
+
+	processed, skipped, failed := runPrinter(outcomes)
+	if processed != 2 {
+		t.Errorf("processed = %d, want 2", processed)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+}
+
+func TestRunPrinterEmpty(t *testing.T) {
+	outcomes := make(chan pipelineOutcome)
+	close(outcomes)
+
+	processed, skipped, failed := runPrinter(outcomes)
+	if processed != 0 || skipped != 0 || failed != 0 {
+		t.Errorf("got (%d, %d, %d), want all zero for no outcomes", processed, skipped, failed)
+	}
+}
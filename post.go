@@ -10,16 +10,23 @@ import (
 )
 
 type PostFrontMatter struct {
-	Title    string                 `yaml:"title,omitempty"`
-	Date     time.Time              `yaml:"date"`
-	Slug     string                 `yaml:"slug"`
-	Tags     []string               `yaml:"tags,omitempty"`
-	Type     string                 `yaml:"type"`
-	Attached []string               `yaml:"attached"`
-	Image    string                 `yaml:"image"`
-	Thumb    string                 `yaml:"thumb"`
-	Language string                 `yaml:"language,omitempty"`
-	Extra    map[string]interface{} `yaml:",inline"`
+	Title    string    `yaml:"title,omitempty"`
+	Date     time.Time `yaml:"date"`
+	Slug     string    `yaml:"slug"`
+	Tags     []string  `yaml:"tags,omitempty"`
+	Type     string    `yaml:"type"`
+	Attached []string  `yaml:"attached"`
+	Image    string    `yaml:"image"`
+	Thumb    string    `yaml:"thumb"`
+	Language string    `yaml:"language,omitempty"`
+	// Sizes maps each configured derivative's name (e.g. "thumb", "small")
+	// to its generated filename.
+	Sizes map[string]string `yaml:"sizes,omitempty"`
+	// Stack maps each attached filename beyond the primary image to its
+	// role ("raw", "alt") when the post was created by stacking related
+	// files together. Absent for posts with a single source image.
+	Stack map[string]string      `yaml:"stack,omitempty"`
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 func createPostDirectory(postsDir string, date time.Time, slug string) (string, error) {
@@ -49,23 +56,22 @@ func createPostDirectoryWithPath(postsDir, relativeDir, slug string) (string, er
 	return postDir, nil
 }
 
-func createMarkdownFile(path, title string, date time.Time, slug string, tags []string, attachedFiles []string, language string, exifFields map[string]interface{}, extraTags []string) error {
-	// Extract image and thumbnail names from attachedFiles
+// buildFrontMatter assembles a post's front matter, merging tags and
+// picking the image/thumbnail names out of attachedFiles. It is shared by
+// createMarkdownFile and createSidecarFile so both always agree on the
+// resolved metadata for a photo.
+func buildFrontMatter(title string, date time.Time, slug string, tags []string, attachedFiles []string, language string, exifFields map[string]interface{}, extraTags []string, stackRoles map[string]string, sizes map[string]string) PostFrontMatter {
 	imageName := ""
-	thumbnailName := ""
 	if len(attachedFiles) > 0 {
 		imageName = attachedFiles[0]
 	}
-	if len(attachedFiles) > 1 {
-		thumbnailName = attachedFiles[1]
-	}
 
 	// Merge extra tags with EXIF-extracted tags
 	allTags := make([]string, 0, len(tags)+len(extraTags))
 	allTags = append(allTags, tags...)
 	allTags = append(allTags, extraTags...)
 
-	frontMatter := PostFrontMatter{
+	return PostFrontMatter{
 		Title:    title,
 		Date:     date,
 		Slug:     slug,
@@ -73,11 +79,15 @@ func createMarkdownFile(path, title string, date time.Time, slug string, tags []
 		Type:     "photo",
 		Attached: attachedFiles,
 		Image:    imageName,
-		Thumb:    thumbnailName,
+		Thumb:    sizes["thumb"],
 		Language: language,
+		Sizes:    sizes,
+		Stack:    stackRoles,
 		Extra:    exifFields,
 	}
+}
 
+func createMarkdownFile(path string, frontMatter PostFrontMatter) error {
 	yamlData, err := yaml.Marshal(frontMatter)
 	if err != nil {
 		return err
@@ -87,3 +97,15 @@ func createMarkdownFile(path, title string, date time.Time, slug string, tags []
 
 	return os.WriteFile(path, []byte(content), 0644)
 }
+
+// createSidecarFile writes a photo's resolved front matter as a standalone
+// YAML document (no markdown delimiters) next to it, so downstream tools
+// can read a photo's metadata back without re-parsing EXIF.
+func createSidecarFile(path string, frontMatter PostFrontMatter) error {
+	yamlData, err := yaml.Marshal(frontMatter)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, yamlData, 0644)
+}
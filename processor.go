@@ -1,17 +1,65 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
-func processSinglePhoto(imagePath, relativeDir string, config *Config, photoTitle string, extraTags []string) error {
+// photoJob carries everything buildPhotoJob resolves from a source image
+// (title, date, slug, tags, metadata) through to writePhotoJob, so the
+// directory pipeline's parse and write stages can each run in their own
+// worker pool without re-reading EXIF data.
+type photoJob struct {
+	imagePath   string
+	relativeDir string
+	config      *Config
+
+	finalTitle string
+	postDate   time.Time
+	slug       string
+	tags       []string
+	exifFields map[string]interface{}
+
+	// videoDuration is set instead of exifFields' usual EXIF-derived data
+	// when imagePath is a video file.
+	videoDuration time.Duration
+
+	// stackMembers are additional files stacked alongside imagePath (e.g.
+	// the RAW half of a RAW+JPEG pair, or the other frames of a burst).
+	// imagePath is always the stack's primary, displayable file.
+	stackMembers []stackMember
+
+	// warnings are non-fatal messages (e.g. unreadable EXIF data) that the
+	// caller prints once it is safe to write to stdout.
+	warnings []string
+}
+
+// buildPhotoJob resolves a photo's title, date, slug, tags and front matter
+// fields without writing anything to disk. This is the "parse" half of
+// processing a photo, split out so processDirectory can run it in parallel.
+func buildPhotoJob(imagePath, relativeDir string, config *Config, photoTitle string, extraTags []string) (*photoJob, error) {
 	// Extract path components for template expansion
 	pathComponents := extractPathComponents(imagePath, postsDir)
 
-	// Determine final title - check CLI flag first, then config
+	job := &photoJob{
+		imagePath:   imagePath,
+		relativeDir: relativeDir,
+		config:      config,
+	}
+
+	// Read an adjacent .xmp/.json sidecar, if any (optional - most photos won't have one)
+	sidecar, err := readSidecar(imagePath)
+	if err != nil {
+		job.warnings = append(job.warnings, fmt.Sprintf("Warning: Could not read sidecar for %s: %v", filepath.Base(imagePath), err))
+		sidecar = nil
+	}
+
+	// Determine final title - CLI flag, then config template, then sidecar
 	finalTitle := ""
 	if photoTitle != "" {
 		// CLI title takes priority - expand if it contains @keywords
@@ -23,21 +71,35 @@ func processSinglePhoto(imagePath, relativeDir string, config *Config, photoTitl
 	} else if config.Metadata.Title != "" {
 		// Fall back to config title template
 		finalTitle = expandTemplate(config.Metadata.Title, pathComponents)
+	} else if sidecar != nil && sidecar.Title != "" {
+		// Fall back to the sidecar's title
+		finalTitle = sidecar.Title
 	}
-
-	// Read EXIF data (optional - may not exist for some images)
-	exifData, err := readExifData(imagePath)
-	if err != nil {
-		fmt.Printf("Warning: Could not read EXIF data from %s: %v\n", filepath.Base(imagePath), err)
-		// Continue processing without EXIF data
-		exifData = nil
+	job.finalTitle = finalTitle
+
+	// Read EXIF data (optional - may not exist for some images). Video
+	// files carry no EXIF/IPTC/XMP box, so skip straight to their own
+	// duration field instead of trying (and failing) to decode one.
+	var exifData *Metadata
+	if isVideoFile(imagePath) {
+		job.videoDuration, err = videoDuration(imagePath, config.Tools)
+		if err != nil {
+			job.warnings = append(job.warnings, fmt.Sprintf("Warning: Could not read duration from %s: %v", filepath.Base(imagePath), err))
+		}
+	} else {
+		exifData, err = readExifData(imagePath)
+		if err != nil {
+			job.warnings = append(job.warnings, fmt.Sprintf("Warning: Could not read EXIF data from %s: %v", filepath.Base(imagePath), err))
+			// Continue processing without EXIF data
+			exifData = nil
+		}
 	}
 
 	// Extract date from EXIF (or use current time as fallback)
-	postDate := extractDate(exifData)
+	job.postDate = extractDate(exifData)
 
 	// Generate slug from title or use timestamp
-	slug := generateSlug(finalTitle, postDate)
+	job.slug = generateSlug(finalTitle, job.postDate)
 
 	// Extract tags from EXIF based on config (if available)
 	tags := extractTags(exifData, config.ExifToTags)
@@ -64,89 +126,229 @@ func processSinglePhoto(imagePath, relativeDir string, config *Config, photoTitl
 		}
 	}
 
-	// Extract EXIF fields for frontmatter
-	exifFields := extractExifFields(exifData, config.ExifToTags)
+	// Sidecar tags are additive, same as CLI/config tags
+	if sidecar != nil {
+		tags = append(tags, sidecar.Tags...)
+	}
+	job.tags = tags
+
+	// Extract EXIF fields for frontmatter, then let the sidecar (if any)
+	// override the fields it carries: CLI > config template > sidecar > EXIF.
+	job.exifFields = extractExifFields(exifData, config.ExifToTags)
+	if job.videoDuration > 0 {
+		if job.exifFields == nil {
+			job.exifFields = make(map[string]interface{})
+		}
+		job.exifFields["video_duration"] = job.videoDuration.Seconds()
+	}
+	if sidecar != nil {
+		if job.exifFields == nil {
+			job.exifFields = make(map[string]interface{})
+		}
+		if sidecar.Description != "" {
+			job.exifFields["description"] = sidecar.Description
+		}
+		if sidecar.Rating != "" {
+			job.exifFields["rating"] = sidecar.Rating
+		}
+		if sidecar.GPS.Valid {
+			job.exifFields["gps"] = sidecar.GPS
+		}
+	}
+
+	return job, nil
+}
+
+// writePhotoJob creates the post directory, stores the image, generates its
+// thumbnail and writes the markdown file. This is the "write" half of
+// processing a photo; it returns errAlreadyImported (wrapped) when
+// content-addressed storage already has this image.
+func writePhotoJob(job *photoJob) (string, error) {
+	imageName := filepath.Base(job.imagePath)
+	ext := filepath.Ext(imageName)
+
+	// Store the source image, either as a copy inside the post directory
+	// (the default) or once under a content-addressed path shared by all
+	// posts, deduplicating re-imports of the same file.
+	var imageRef string
+	if job.config.Storage == StorageContentAddressed {
+		ref, err := storeContentAddressed(job.imagePath, postsDir, ext)
+		if err != nil {
+			if errors.Is(err, errAlreadyImported) {
+				return "", err
+			}
+			return "", fmt.Errorf("error storing image: %w", err)
+		}
+		imageRef = ref
+	}
 
 	// Create post directory structure
-	postDir, err := createPostDirectoryWithPath(postsDir, relativeDir, slug)
+	postDir, err := createPostDirectoryWithPath(postsDir, job.relativeDir, job.slug)
 	if err != nil {
-		return fmt.Errorf("error creating post directory: %w", err)
+		return "", fmt.Errorf("error creating post directory: %w", err)
 	}
 
-	// Copy image to post directory
-	imageName := filepath.Base(imagePath)
-	destImage := filepath.Join(postDir, imageName)
-	if err := copyFile(imagePath, destImage); err != nil {
-		return fmt.Errorf("error copying image: %w", err)
+	if imageRef == "" {
+		// Copy image to post directory
+		destImage := filepath.Join(postDir, imageName)
+		if err := copyFile(job.imagePath, destImage); err != nil {
+			return "", fmt.Errorf("error copying image: %w", err)
+		}
+		imageRef = imageName
+	}
+
+	// Generate each configured derivative (thumbnails, previews, ...),
+	// falling back to the original single 800x800 thumbnail when the
+	// config doesn't list any.
+	derivatives := job.config.Derivatives
+	if len(derivatives) == 0 {
+		derivatives = defaultDerivatives
 	}
 
-	// Create thumbnail
-	ext := filepath.Ext(imageName)
 	nameWithoutExt := strings.TrimSuffix(imageName, ext)
-	thumbnailName := nameWithoutExt + "-thumb" + ext
-	thumbnailPath := filepath.Join(postDir, thumbnailName)
-	if err := createThumbnail(imagePath, thumbnailPath, 800, 800); err != nil {
-		return fmt.Errorf("error creating thumbnail: %w", err)
+	sizes := make(map[string]string, len(derivatives))
+	attachedFiles := []string{imageRef}
+	for _, spec := range derivatives {
+		derivativeName := nameWithoutExt + "-" + spec.Name + derivativeExt(spec, ext)
+		derivativePath := filepath.Join(postDir, derivativeName)
+		if err := generateDerivative(job.imagePath, derivativePath, spec, job.config.Tools); err != nil {
+			return "", fmt.Errorf("error generating derivative %q: %w", spec.Name, err)
+		}
+		sizes[spec.Name] = derivativeName
+		attachedFiles = append(attachedFiles, derivativeName)
+	}
+
+	// Store any stacked files (RAW sibling, other burst frames) alongside
+	// the primary image, tracking each one's role so the front matter can
+	// say which attached file is what.
+	var stackRoles map[string]string
+	if len(job.stackMembers) > 0 {
+		stackRoles = map[string]string{imageRef: "primary"}
+		for _, member := range job.stackMembers {
+			memberRef, err := storeStackMember(member, job.config, postDir)
+			if err != nil {
+				if errors.Is(err, errAlreadyImported) {
+					// Already stored by a previous import - nothing new to
+					// attach, but not a reason to fail the whole stack.
+					continue
+				}
+				return "", fmt.Errorf("error storing stacked file %s: %w", filepath.Base(member.path), err)
+			}
+			attachedFiles = append(attachedFiles, memberRef)
+			stackRoles[memberRef] = stackRole(member.ext)
+		}
 	}
 
 	// Create markdown file with front matter
-	attachedFiles := []string{imageName, thumbnailName}
-	mdFile := filepath.Join(postDir, slug+".md")
-	if err := createMarkdownFile(mdFile, finalTitle, postDate, slug, tags, attachedFiles, language, exifFields, nil); err != nil {
-		return fmt.Errorf("error creating markdown file: %w", err)
+	frontMatter := buildFrontMatter(job.finalTitle, job.postDate, job.slug, job.tags, attachedFiles, language, job.exifFields, nil, stackRoles, sizes)
+
+	mdFile := filepath.Join(postDir, job.slug+".md")
+	if err := createMarkdownFile(mdFile, frontMatter); err != nil {
+		return "", fmt.Errorf("error creating markdown file: %w", err)
+	}
+
+	// Emit a photo.yml sidecar with the same resolved metadata, so it can
+	// be re-read without parsing EXIF again.
+	sidecarFile := filepath.Join(postDir, "photo.yml")
+	if err := createSidecarFile(sidecarFile, frontMatter); err != nil {
+		return "", fmt.Errorf("error creating metadata sidecar: %w", err)
+	}
+
+	return postDir, nil
+}
+
+func processSinglePhoto(imagePath, relativeDir string, config *Config, photoTitle string, extraTags []string) error {
+	job, err := buildPhotoJob(imagePath, relativeDir, config, photoTitle, extraTags)
+	if err != nil {
+		return err
+	}
+	for _, warning := range job.warnings {
+		fmt.Println(warning)
+	}
+
+	postDir, err := writePhotoJob(job)
+	if err != nil {
+		if errors.Is(err, errAlreadyImported) {
+			fmt.Printf("Skipping %s: %v\n", filepath.Base(imagePath), err)
+			return nil
+		}
+		return err
 	}
 
 	fmt.Printf("Post created successfully at: %s\n", postDir)
 	return nil
 }
 
-func processDirectory(baseDir string, config *Config, title string, extraTags []string) error {
-	fmt.Printf("Processing directory: %s\n", baseDir)
+// errAlreadyImported signals that a source image already exists under the
+// content-addressed store, so processSinglePhoto should skip creating a
+// duplicate post.
+var errAlreadyImported = errors.New("already imported")
+
+// contentAddressedLocks guards each hash's check-and-create sequence in
+// storeContentAddressed, so two goroutines importing identical content at
+// the same time (duplicate files in a library, processed concurrently by
+// the worker pool) can't both pass the not-exists check and race on the
+// same destination path.
+var contentAddressedLocks sync.Map // hash string -> *sync.Mutex
+
+func lockForHash(hash string) *sync.Mutex {
+	mu, _ := contentAddressedLocks.LoadOrStore(hash, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
 
-	// Walk the directory tree
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// storeContentAddressed hashes the source image and copies it into
+// postsDir/content/<xx>/<rest>.<ext> if it isn't already there, returning
+// the path (relative to postsDir) that posts should reference.
+func storeContentAddressed(imagePath, postsDir, ext string) (string, error) {
+	hash, err := hashFile(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("error hashing image: %w", err)
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	mu := lockForHash(hash)
+	mu.Lock()
+	defer mu.Unlock()
 
-		// Check if file is an image
-		if !isImageFile(path) {
-			return nil
-		}
+	relPath := contentAddressedRelPath(hash, ext)
+	absPath := filepath.Join(postsDir, relPath)
 
-		// Calculate relative path from base directory
-		relPath, err := filepath.Rel(baseDir, path)
-		if err != nil {
-			return fmt.Errorf("error calculating relative path: %w", err)
-		}
+	if _, err := os.Stat(absPath); err == nil {
+		return "", fmt.Errorf("%w as %s", errAlreadyImported, relPath)
+	}
 
-		// Get directory part (without filename)
-		relDir := filepath.Dir(relPath)
-		if relDir == "." {
-			relDir = ""
-		}
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return "", err
+	}
+	if err := copyFile(imagePath, absPath); err != nil {
+		return "", err
+	}
 
-		// Process this image
-		fmt.Printf("Processing: %s\n", path)
-		if err := processSinglePhoto(path, relDir, config, title, extraTags); err != nil {
-			fmt.Printf("Error processing %s: %v\n", path, err)
-			// Continue processing other files
-		}
+	return relPath, nil
+}
 
-		return nil
-	})
+// storeStackMember stores one non-primary file of a stack (e.g. a RAW
+// sibling) the same way writePhotoJob stores the primary image - either
+// copied into postDir or deduplicated under content-addressed storage -
+// and returns the path posts should reference.
+func storeStackMember(member stackMember, config *Config, postDir string) (string, error) {
+	memberName := filepath.Base(member.path)
 
-	if err != nil {
-		return fmt.Errorf("error walking directory: %w", err)
+	if config.Storage == StorageContentAddressed {
+		ref, err := storeContentAddressed(member.path, postsDir, member.ext)
+		if err != nil {
+			if errors.Is(err, errAlreadyImported) {
+				return "", err
+			}
+			return "", err
+		}
+		return ref, nil
 	}
 
-	fmt.Println("Directory processing complete")
-	return nil
+	dest := filepath.Join(postDir, memberName)
+	if err := copyFile(member.path, dest); err != nil {
+		return "", err
+	}
+	return memberName, nil
 }
 
 // extractPathComponents extracts components from a file path for template expansion
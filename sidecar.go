@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SidecarMetadata is metadata read from a file next to the source image
+// (an .xmp packet or a .json file), merged into a photo's front matter
+// with precedence: CLI > config template > sidecar > EXIF.
+type SidecarMetadata struct {
+	Title       string
+	Description string
+	Tags        []string
+	Rating      string
+	GPS         GPSCoordinates
+}
+
+// readSidecar looks for, in order, "<base>.xmp", "<base>.json" and
+// "<imageName>.json" next to imagePath and parses whichever is found
+// first. It returns (nil, nil) when no sidecar exists.
+func readSidecar(imagePath string) (*SidecarMetadata, error) {
+	dir := filepath.Dir(imagePath)
+	base := strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath))
+
+	candidates := []string{
+		filepath.Join(dir, base+".xmp"),
+		filepath.Join(dir, base+".json"),
+		imagePath + ".json",
+	}
+
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		if strings.EqualFold(filepath.Ext(candidate), ".xmp") {
+			return parseXMPSidecar(data)
+		}
+		return parseJSONSidecar(data)
+	}
+
+	return nil, nil
+}
+
+// jsonSidecarFile is the shape of a plain JSON sidecar.
+type jsonSidecarFile struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Keywords    []string `json:"keywords"`
+	Rating      string   `json:"rating"`
+	GPS         *struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+		Alt float64 `json:"alt"`
+	} `json:"gps"`
+}
+
+func parseJSONSidecar(data []byte) (*SidecarMetadata, error) {
+	var raw jsonSidecarFile
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	meta := &SidecarMetadata{
+		Title:       raw.Title,
+		Description: raw.Description,
+		Tags:        append(append([]string{}, raw.Tags...), raw.Keywords...),
+		Rating:      raw.Rating,
+	}
+	if raw.GPS != nil {
+		meta.GPS = GPSCoordinates{Latitude: raw.GPS.Lat, Longitude: raw.GPS.Lon, Altitude: raw.GPS.Alt, Valid: true}
+	}
+
+	return meta, nil
+}
+
+// xmpPacket models the handful of Dublin Core / XMP fields a sidecar file
+// is likely to carry. Tags are matched by local name only, so the
+// namespace prefixes used by different writers (Adobe, exiftool, digiKam)
+// all resolve the same way.
+type xmpPacket struct {
+	Description struct {
+		Title       xmpLangAlt `xml:"title"`
+		Description xmpLangAlt `xml:"description"`
+		Subject     xmpBag     `xml:"subject"`
+		Rating      string     `xml:"Rating"`
+	} `xml:"RDF>Description"`
+}
+
+type xmpLangAlt struct {
+	Items []string `xml:"Alt>li"`
+}
+
+type xmpBag struct {
+	Items []string `xml:"Bag>li"`
+}
+
+func parseXMPSidecar(data []byte) (*SidecarMetadata, error) {
+	var packet xmpPacket
+	if err := xml.Unmarshal(data, &packet); err != nil {
+		return nil, err
+	}
+
+	meta := &SidecarMetadata{
+		Tags: packet.Description.Subject.Items,
+	}
+	if len(packet.Description.Title.Items) > 0 {
+		meta.Title = packet.Description.Title.Items[0]
+	}
+	if len(packet.Description.Description.Items) > 0 {
+		meta.Description = packet.Description.Description.Items[0]
+	}
+	if rating := strings.TrimSpace(packet.Description.Rating); rating != "" {
+		if _, err := strconv.Atoi(rating); err == nil {
+			meta.Rating = rating
+		}
+	}
+
+	return meta, nil
+}
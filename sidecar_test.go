@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJSONSidecar(t *testing.T) {
+	data := []byte(`{
+		"title": "Sunset over the bay",
+		"description": "Shot from the pier",
+		"tags": ["sunset"],
+		"keywords": ["bay", "pier"],
+		"rating": "4",
+		"gps": {"lat": 37.8, "lon": -122.4, "alt": 12.5}
+	}`)
+
+	meta, err := parseJSONSidecar(data)
+	if err != nil {
+		t.Fatalf("parseJSONSidecar: %v", err)
+	}
+
+	if meta.Title != "Sunset over the bay" {
+		t.Errorf("Title = %q, want %q", meta.Title, "Sunset over the bay")
+	}
+	if meta.Rating != "4" {
+		t.Errorf("Rating = %q, want %q", meta.Rating, "4")
+	}
+	wantTags := []string{"sunset", "bay", "pier"}
+	if len(meta.Tags) != len(wantTags) {
+		t.Fatalf("Tags = %v, want %v", meta.Tags, wantTags)
+	}
+	for i, tag := range wantTags {
+		if meta.Tags[i] != tag {
+			t.Errorf("Tags[%d] = %q, want %q", i, meta.Tags[i], tag)
+		}
+	}
+	if !meta.GPS.Valid || meta.GPS.Latitude != 37.8 || meta.GPS.Longitude != -122.4 {
+		t.Errorf("GPS = %+v, want lat 37.8, lon -122.4", meta.GPS)
+	}
+}
+
+func TestParseJSONSidecarNoGPS(t *testing.T) {
+	meta, err := parseJSONSidecar([]byte(`{"title": "No location"}`))
+	if err != nil {
+		t.Fatalf("parseJSONSidecar: %v", err)
+	}
+	if meta.GPS.Valid {
+		t.Errorf("GPS.Valid = true, want false when the sidecar has no gps object")
+	}
+}
+
+func TestParseXMPSidecar(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+      <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Golden Gate</rdf:li></rdf:Alt></dc:title>
+      <dc:description><rdf:Alt><rdf:li xml:lang="x-default">Fog rolling in</rdf:li></rdf:Alt></dc:description>
+      <dc:subject><rdf:Bag><rdf:li>bridge</rdf:li><rdf:li>fog</rdf:li></rdf:Bag></dc:subject>
+      <xmp:Rating>5</xmp:Rating>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>`)
+
+	meta, err := parseXMPSidecar(data)
+	if err != nil {
+		t.Fatalf("parseXMPSidecar: %v", err)
+	}
+
+	if meta.Title != "Golden Gate" {
+		t.Errorf("Title = %q, want %q", meta.Title, "Golden Gate")
+	}
+	if meta.Description != "Fog rolling in" {
+		t.Errorf("Description = %q, want %q", meta.Description, "Fog rolling in")
+	}
+	if meta.Rating != "5" {
+		t.Errorf("Rating = %q, want %q", meta.Rating, "5")
+	}
+	if len(meta.Tags) != 2 || meta.Tags[0] != "bridge" || meta.Tags[1] != "fog" {
+		t.Errorf("Tags = %v, want [bridge fog]", meta.Tags)
+	}
+}
+
+func TestParseXMPSidecarInvalidRatingIgnored(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+      <xmp:Rating>not-a-number</xmp:Rating>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>`)
+
+	meta, err := parseXMPSidecar(data)
+	if err != nil {
+		t.Fatalf("parseXMPSidecar: %v", err)
+	}
+	if meta.Rating != "" {
+		t.Errorf("Rating = %q, want empty for a non-numeric rating", meta.Rating)
+	}
+}
+
+func TestReadSidecarPrefersXMPOverJSON(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "photo.jpg")
+
+	if err := os.WriteFile(filepath.Join(dir, "photo.json"), []byte(`{"title": "from json"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	xmpData := []byte(`<?xml version="1.0"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+  <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+    <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+      <dc:title><rdf:Alt><rdf:li>from xmp</rdf:li></rdf:Alt></dc:title>
+    </rdf:Description>
+  </rdf:RDF>
+</x:xmpmeta>`)
+	if err := os.WriteFile(filepath.Join(dir, "photo.xmp"), xmpData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := readSidecar(imagePath)
+	if err != nil {
+		t.Fatalf("readSidecar: %v", err)
+	}
+	if meta == nil {
+		t.Fatal("readSidecar returned nil, want a sidecar")
+	}
+	if meta.Title != "from xmp" {
+		t.Errorf("Title = %q, want %q (xmp should win over json)", meta.Title, "from xmp")
+	}
+}
+
+func TestReadSidecarNone(t *testing.T) {
+	dir := t.TempDir()
+	meta, err := readSidecar(filepath.Join(dir, "photo.jpg"))
+	if err != nil {
+		t.Fatalf("readSidecar: %v", err)
+	}
+	if meta != nil {
+		t.Errorf("readSidecar = %+v, want nil when no sidecar exists", meta)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultStackExtensionPriority ranks the formats we can actually generate
+// a thumbnail from ahead of everything else, so a stack's primary file is
+// always something displayable; RAW and other formats fall back to being
+// listed as a sidecar of the primary.
+var defaultStackExtensionPriority = []string{"jpg", "jpeg", "heic", "heif", "png", "tif", "tiff"}
+
+// rawExtensions marks formats that are never a good display candidate even
+// if nothing else is in the stack, so their stack role is reported as "raw"
+// rather than "alt".
+var rawExtensions = map[string]bool{
+	"cr2": true, "nef": true, "arw": true, "dng": true,
+	"raf": true, "orf": true, "rw2": true, "pef": true, "srw": true,
+}
+
+// stackMember is one file that might belong to a stack, carrying just
+// enough metadata (capture time + camera body) to detect bursts that
+// don't share a basename with anything else.
+type stackMember struct {
+	path       string
+	relDir     string
+	basename   string
+	ext        string
+	capturedAt time.Time
+	camera     string
+}
+
+// stackGroup is a set of related files - a RAW+JPEG pair, a burst
+// sequence, or a lone file - that becomes a single post. primary is
+// always members[0].
+type stackGroup struct {
+	relativeDir string
+	primary     stackMember
+	members     []stackMember
+}
+
+// readCaptureInfo reads just enough EXIF to group a file into a stack. It
+// returns the zero time when no capture date is available, rather than
+// extractDate's "now" fallback, so files without EXIF never get grouped by
+// time - only by shared basename.
+func readCaptureInfo(path string) (time.Time, string) {
+	meta, err := readExifData(path)
+	if err != nil || meta == nil {
+		return time.Time{}, ""
+	}
+
+	dateStr := meta.EXIF["DateTimeOriginal"]
+	if dateStr == "" {
+		dateStr = meta.EXIF["DateTime"]
+	}
+	if dateStr == "" {
+		return time.Time{}, meta.EXIF["Model"]
+	}
+
+	capturedAt, err := time.Parse("2006:01:02 15:04:05", dateStr)
+	if err != nil {
+		return time.Time{}, meta.EXIF["Model"]
+	}
+
+	return capturedAt, meta.EXIF["Model"]
+}
+
+// extensionRank says how preferred an extension is as a stack's primary
+// file: lower is better. Extensions absent from priority sort after every
+// listed one.
+func extensionRank(ext string, priority []string) int {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	for i, candidate := range priority {
+		if candidate == ext {
+			return i
+		}
+	}
+	return len(priority)
+}
+
+func stackRole(ext string) string {
+	if rawExtensions[strings.ToLower(strings.TrimPrefix(ext, "."))] {
+		return "raw"
+	}
+	return "alt"
+}
+
+// groupStacks groups members into stacks: first by shared basename
+// (RAW+JPEG pairs, same name different extension), then by camera+capture
+// time proximity for files that don't share a basename with anything
+// (burst sequences). Anything left ungrouped becomes its own single-file
+// stack, so callers can treat every import the same way.
+func groupStacks(members []stackMember, window time.Duration, extensionPriority []string) []stackGroup {
+	if len(extensionPriority) == 0 {
+		extensionPriority = defaultStackExtensionPriority
+	}
+
+	byBasename := make(map[string][]stackMember)
+	var order []string
+	for _, m := range members {
+		key := filepath.Join(m.relDir, m.basename)
+		if _, ok := byBasename[key]; !ok {
+			order = append(order, key)
+		}
+		byBasename[key] = append(byBasename[key], m)
+	}
+
+	var groups []stackGroup
+	var leftover []stackMember
+	for _, key := range order {
+		group := byBasename[key]
+		if len(group) > 1 {
+			groups = append(groups, newStackGroup(group, extensionPriority))
+		} else {
+			leftover = append(leftover, group...)
+		}
+	}
+
+	sort.Slice(leftover, func(i, j int) bool {
+		return leftover[i].capturedAt.Before(leftover[j].capturedAt)
+	})
+
+	used := make([]bool, len(leftover))
+	for i := range leftover {
+		if used[i] || leftover[i].capturedAt.IsZero() {
+			continue
+		}
+
+		group := []stackMember{leftover[i]}
+		used[i] = true
+
+		for j := i + 1; j < len(leftover); j++ {
+			if used[j] || leftover[j].capturedAt.IsZero() {
+				continue
+			}
+			if leftover[j].camera != leftover[i].camera || leftover[j].relDir != leftover[i].relDir {
+				continue
+			}
+			if leftover[j].capturedAt.Sub(group[len(group)-1].capturedAt) > window {
+				break
+			}
+			group = append(group, leftover[j])
+			used[j] = true
+		}
+
+		groups = append(groups, newStackGroup(group, extensionPriority))
+	}
+
+	for i, m := range leftover {
+		if !used[i] {
+			groups = append(groups, newStackGroup([]stackMember{m}, extensionPriority))
+		}
+	}
+
+	return groups
+}
+
+func newStackGroup(members []stackMember, extensionPriority []string) stackGroup {
+	sort.SliceStable(members, func(i, j int) bool {
+		return extensionRank(members[i].ext, extensionPriority) < extensionRank(members[j].ext, extensionPriority)
+	})
+
+	return stackGroup{
+		relativeDir: members[0].relDir,
+		primary:     members[0],
+		members:     members,
+	}
+}
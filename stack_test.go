@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtensionRank(t *testing.T) {
+	priority := []string{"jpg", "heic", "png"}
+
+	cases := []struct {
+		ext  string
+		want int
+	}{
+		{"jpg", 0},
+		{".JPG", 0}, // leading dot and case are normalized
+		{"heic", 1},
+		{"png", 2},
+		{"cr2", len(priority)}, // not in priority, sorts last
+	}
+
+	for _, c := range cases {
+		if got := extensionRank(c.ext, priority); got != c.want {
+			t.Errorf("extensionRank(%q) = %d, want %d", c.ext, got, c.want)
+		}
+	}
+}
+
+func TestStackRole(t *testing.T) {
+	if role := stackRole(".cr2"); role != "raw" {
+		t.Errorf("stackRole(.cr2) = %q, want raw", role)
+	}
+	if role := stackRole("nef"); role != "raw" {
+		t.Errorf("stackRole(nef) = %q, want raw", role)
+	}
+	if role := stackRole(".heic"); role != "alt" {
+		t.Errorf("stackRole(.heic) = %q, want alt", role)
+	}
+}
+
+func TestGroupStacksBasenamePair(t *testing.T) {
+	members := []stackMember{
+		{path: "a.jpg", relDir: "", basename: "a", ext: ".jpg"},
+		{path: "a.cr2", relDir: "", basename: "a", ext: ".cr2"},
+	}
+
+	groups := groupStacks(members, 2*time.Second, nil)
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(groups))
+	}
+	if len(groups[0].members) != 2 {
+		t.Fatalf("got %d members, want 2", len(groups[0].members))
+	}
+	if groups[0].primary.ext != ".jpg" {
+		t.Errorf("primary ext = %q, want .jpg (JPEG outranks RAW)", groups[0].primary.ext)
+	}
+}
+
+func TestGroupStacksBurstWithinWindow(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	members := []stackMember{
+		{path: "1.jpg", basename: "1", ext: ".jpg", capturedAt: base, camera: "X100"},
+		{path: "2.jpg", basename: "2", ext: ".jpg", capturedAt: base.Add(1 * time.Second), camera: "X100"},
+		{path: "3.jpg", basename: "3", ext: ".jpg", capturedAt: base.Add(10 * time.Second), camera: "X100"},
+	}
+
+	groups := groupStacks(members, 2*time.Second, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2 (a 2-frame burst plus a separate shot)", len(groups))
+	}
+
+	var burst, single stackGroup
+	for _, g := range groups {
+		if len(g.members) == 2 {
+			burst = g
+		} else {
+			single = g
+		}
+	}
+	if len(burst.members) != 2 {
+		t.Fatalf("burst group has %d members, want 2", len(burst.members))
+	}
+	if len(single.members) != 1 || single.primary.path != "3.jpg" {
+		t.Errorf("single group = %+v, want just 3.jpg", single)
+	}
+}
+
+func TestGroupStacksDifferentCamerasDontMerge(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	members := []stackMember{
+		{path: "1.jpg", basename: "1", ext: ".jpg", capturedAt: base, camera: "CameraA"},
+		{path: "2.jpg", basename: "2", ext: ".jpg", capturedAt: base.Add(1 * time.Second), camera: "CameraB"},
+	}
+
+	groups := groupStacks(members, 2*time.Second, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2 (different cameras should not be grouped into a burst)", len(groups))
+	}
+}
+
+func TestGroupStacksNoCaptureTimeStaysSingle(t *testing.T) {
+	members := []stackMember{
+		{path: "1.jpg", basename: "1", ext: ".jpg"},
+		{path: "2.jpg", basename: "2", ext: ".jpg"},
+	}
+
+	groups := groupStacks(members, 2*time.Second, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2 (files without a capture time should never be grouped together)", len(groups))
+	}
+}
@@ -28,7 +28,15 @@ func generateSlug(title string, date time.Time) string {
 
 func isImageFile(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
-	imageExtensions := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp"}
+	imageExtensions := []string{
+		".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp",
+		".heic", ".heif", ".cr2", ".nef", ".arw", ".dng",
+		".raf", ".orf", ".rw2", ".pef", ".srw",
+		// Video sidecars: decodeImageForThumbnail grabs a first-frame
+		// thumbnail and buildPhotoJob records their duration instead of
+		// EXIF data.
+		".mov", ".mp4",
+	}
 
 	for _, imgExt := range imageExtensions {
 		if ext == imgExt {